@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/tendermint/tendermint/crypto"
+	tmtypes "github.com/tendermint/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/cosmos/cosmos-sdk/server"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/auth"
+	"github.com/cosmos/cosmos-sdk/x/genutil"
+	"github.com/cosmos/cosmos-sdk/x/genutil/types"
+)
+
+const (
+	flagGenTxDir         = "gentx-dir"
+	flagMaxPowerFraction = "max-power-fraction"
+)
+
+// poaCreateValidatorMsg is the subset of sdk.Msg that a POA create-validator
+// message must implement so collect-gentxs can dedupe and seed the validator
+// set without importing a concrete POA message type.
+type poaCreateValidatorMsg interface {
+	sdk.Msg
+	GetValidatorAddress() sdk.ValAddress
+	GetDelegatorAddress() sdk.AccAddress
+	GetPubKey() crypto.PubKey
+}
+
+// poaWeightedValidatorMsg is an optional extension of poaCreateValidatorMsg
+// implemented by messages built via BuildCreateValidatorMsgV2, which carry an
+// explicit consensus power instead of a token self-delegation amount, plus
+// the validator's configured moniker.
+type poaWeightedValidatorMsg interface {
+	poaCreateValidatorMsg
+	GetPower() int64
+	GetMoniker() string
+}
+
+// validatorPower returns msg's explicit power when it implements
+// poaWeightedValidatorMsg, or 1 (a plain self-delegation-style validator)
+// otherwise.
+func validatorPower(msg poaCreateValidatorMsg) int64 {
+	if weighted, ok := msg.(poaWeightedValidatorMsg); ok {
+		return weighted.GetPower()
+	}
+	return 1
+}
+
+// validatorName returns msg's configured moniker when it implements
+// poaWeightedValidatorMsg and has one set, falling back to operatorKey (the
+// validator's bech32 operator address) otherwise.
+func validatorName(msg poaCreateValidatorMsg, operatorKey string) string {
+	if weighted, ok := msg.(poaWeightedValidatorMsg); ok && weighted.GetMoniker() != "" {
+		return weighted.GetMoniker()
+	}
+	return operatorKey
+}
+
+// CollectGenTxsCmd builds the command used to aggregate every gentx produced
+// by GenTxCmd into the final genesis.json.
+func CollectGenTxsCmd(ctx *server.Context, cdc *codec.Codec, genAccIterator types.GenesisAccountsIterator, defaultNodeHome string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "collect-gentxs",
+		Short: "Collect genesis txs and output a genesis.json file",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			config := ctx.Config
+			config.SetRoot(viper.GetString(client.FlagHome))
+
+			genFile := config.GenesisFile()
+			genDoc, err := tmtypes.GenesisDocFromFile(genFile)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read genesis doc file %s", genFile)
+			}
+
+			var genesisState map[string]json.RawMessage
+			if err := cdc.UnmarshalJSON(genDoc.AppState, &genesisState); err != nil {
+				return errors.Wrap(err, "failed to unmarshal genesis state")
+			}
+
+			gentxDir := viper.GetString(flagGenTxDir)
+			if gentxDir == "" {
+				gentxDir = filepath.Join(config.RootDir, "config", "gentx")
+			}
+
+			stdTxs, err := loadGenTxFiles(cdc, gentxDir)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load gentxs from %s", gentxDir)
+			}
+
+			validators, genTxs, err := processGenTxs(stdTxs, genesisState, genAccIterator, cdc, viper.GetFloat64(flagMaxPowerFraction))
+			if err != nil {
+				return err
+			}
+
+			if genesisState, err = genutil.SetGenTxsInAppGenesisState(cdc, genesisState, genTxs); err != nil {
+				return errors.Wrap(err, "failed to set gentxs in genesis state")
+			}
+
+			appStateJSON, err := codec.MarshalJSONIndent(cdc, genesisState)
+			if err != nil {
+				return errors.Wrap(err, "failed to marshal application genesis state")
+			}
+
+			genDoc.AppState = appStateJSON
+			genDoc.Validators = validators
+
+			return exportGenesisFileAtomically(genDoc, genFile)
+		},
+	}
+
+	cmd.Flags().String(client.FlagHome, defaultNodeHome, "node's home directory")
+	cmd.Flags().String(flagGenTxDir, "",
+		"override default \"gentx\" directory from which collect and execute genesis transactions; default [--home]/config/gentx/")
+	cmd.Flags().Float64(flagMaxPowerFraction, 0,
+		"reject the genesis if any single validator's power exceeds this fraction of total power; 0 disables the check")
+	return cmd
+}
+
+// loadGenTxFiles reads and unmarshals every gentx-*.json file in dir.
+func loadGenTxFiles(cdc *codec.Codec, dir string) ([]auth.StdTx, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "gentx-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	stdTxs := make([]auth.StdTx, 0, len(files))
+	for _, f := range files {
+		bz, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read gentx file %s", f)
+		}
+
+		var stdTx auth.StdTx
+		if err := cdc.UnmarshalJSON(bz, &stdTx); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse gentx file %s", f)
+		}
+		stdTxs = append(stdTxs, stdTx)
+	}
+	return stdTxs, nil
+}
+
+// processGenTxs validates every collected gentx, deduplicates validators by
+// consensus pubkey and operator address, and checks each signer is a known
+// genesis account. It returns the tendermint validator set derived from the
+// surviving gentxs alongside the raw txs to embed in the genutil genesis
+// state. maxPowerFraction, if non-zero, rejects any validator whose power
+// exceeds that fraction of the total collected power.
+func processGenTxs(stdTxs []auth.StdTx, genesisState map[string]json.RawMessage,
+	genAccIterator types.GenesisAccountsIterator, cdc *codec.Codec, maxPowerFraction float64) ([]tmtypes.GenesisValidator, []auth.StdTx, error) {
+
+	seenPubKeys := make(map[string]bool)
+	seenOperators := make(map[string]bool)
+
+	validators := make([]tmtypes.GenesisValidator, 0, len(stdTxs))
+	genTxs := make([]auth.StdTx, 0, len(stdTxs))
+
+	var totalPower int64
+	for i, stdTx := range stdTxs {
+		msg, err := validateGenTxMsg(stdTx)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid gentx at index %d", i)
+		}
+
+		pubKeyKey := string(msg.GetPubKey().Bytes())
+		if seenPubKeys[pubKeyKey] {
+			return nil, nil, fmt.Errorf("duplicate validator consensus pubkey in gentx at index %d", i)
+		}
+		seenPubKeys[pubKeyKey] = true
+
+		operatorKey := msg.GetValidatorAddress().String()
+		if seenOperators[operatorKey] {
+			return nil, nil, fmt.Errorf("duplicate validator operator address %s", operatorKey)
+		}
+		seenOperators[operatorKey] = true
+
+		if err := genutil.ValidateAccountInGenesis(
+			genesisState, genAccIterator, msg.GetDelegatorAddress(), sdk.Coins{}, cdc,
+		); err != nil {
+			return nil, nil, errors.Wrapf(err, "gentx signer %s is not a genesis account", msg.GetDelegatorAddress())
+		}
+
+		power := validatorPower(msg)
+		if power <= 0 {
+			return nil, nil, fmt.Errorf("validator %s has non-positive power %d", operatorKey, power)
+		}
+
+		newTotal := totalPower + power
+		if newTotal < totalPower {
+			return nil, nil, fmt.Errorf("total validator power overflows int64 at gentx index %d", i)
+		}
+		totalPower = newTotal
+
+		validators = append(validators, tmtypes.GenesisValidator{
+			PubKey: msg.GetPubKey(),
+			Power:  power,
+			Name:   validatorName(msg, operatorKey),
+		})
+		genTxs = append(genTxs, stdTx)
+	}
+
+	if maxPowerFraction > 0 {
+		for _, v := range validators {
+			if fraction := float64(v.Power) / float64(totalPower); fraction > maxPowerFraction {
+				return nil, nil, fmt.Errorf("validator %s holds %.2f%% of total power, exceeding --%s of %.2f%%",
+					v.Name, fraction*100, flagMaxPowerFraction, maxPowerFraction*100)
+			}
+		}
+	}
+
+	return validators, genTxs, nil
+}
+
+// validateGenTxMsg checks that stdTx carries exactly one signed POA
+// create-validator message and returns it.
+func validateGenTxMsg(stdTx auth.StdTx) (poaCreateValidatorMsg, error) {
+	msgs := stdTx.GetMsgs()
+	if len(msgs) != 1 {
+		return nil, fmt.Errorf("expected exactly one message, got %d", len(msgs))
+	}
+
+	// Guard against an unsigned gentx (e.g. one left behind by `gentx
+	// --generate-only` and never routed through the offline-signing step)
+	// being silently collected into the genesis validator set, the same way
+	// AddGenTxCmd already guards against it on the add-gentx path.
+	if len(stdTx.GetSignatures()) == 0 {
+		return nil, fmt.Errorf("gentx does not contain any signatures")
+	}
+
+	msg := msgs[0]
+	if msg.Route() != "poa" || msg.Type() != "create_validator" {
+		return nil, fmt.Errorf("expected a poa MsgCreateValidator, got route %q type %q", msg.Route(), msg.Type())
+	}
+
+	createValMsg, ok := msg.(poaCreateValidatorMsg)
+	if !ok {
+		return nil, fmt.Errorf("message does not implement the expected create-validator accessors")
+	}
+	return createValMsg, nil
+}
+
+// exportGenesisFileAtomically writes genDoc to genFile by writing to a
+// temporary file in the same directory and renaming it into place, so a
+// crash mid-write never leaves a corrupt genesis.json behind.
+func exportGenesisFileAtomically(genDoc *tmtypes.GenesisDoc, genFile string) error {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(genFile), "genesis-*.json.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp genesis file")
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+
+	if err := genDoc.SaveAs(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrap(err, "failed to save genesis doc")
+	}
+
+	if err := os.Rename(tmpPath, genFile); err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrap(err, "failed to move genesis doc into place")
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tendermint/tendermint/crypto"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/auth"
+	authexported "github.com/cosmos/cosmos-sdk/x/auth/exported"
+)
+
+// fakeCreateValidatorMsg is a minimal poaCreateValidatorMsg used to drive the
+// collect-gentxs validation logic without a concrete POA message type.
+type fakeCreateValidatorMsg struct {
+	operator  sdk.ValAddress
+	delegator sdk.AccAddress
+	pubKey    crypto.PubKey
+	route     string
+	msgType   string
+}
+
+func (m fakeCreateValidatorMsg) Route() string                      { return m.route }
+func (m fakeCreateValidatorMsg) Type() string                       { return m.msgType }
+func (m fakeCreateValidatorMsg) ValidateBasic() sdk.Error            { return nil }
+func (m fakeCreateValidatorMsg) GetSignBytes() []byte                { return []byte("fake") }
+func (m fakeCreateValidatorMsg) GetSigners() []sdk.AccAddress        { return []sdk.AccAddress{m.delegator} }
+func (m fakeCreateValidatorMsg) GetValidatorAddress() sdk.ValAddress { return m.operator }
+func (m fakeCreateValidatorMsg) GetDelegatorAddress() sdk.AccAddress { return m.delegator }
+func (m fakeCreateValidatorMsg) GetPubKey() crypto.PubKey            { return m.pubKey }
+
+// fakeCreateValidatorMsgRepr is the exported-field form amino encodes
+// fakeCreateValidatorMsg as, since amino skips unexported struct fields.
+type fakeCreateValidatorMsgRepr struct {
+	Operator  sdk.ValAddress
+	Delegator sdk.AccAddress
+	PubKey    crypto.PubKey
+	Route     string
+	MsgType   string
+}
+
+func (m fakeCreateValidatorMsg) MarshalAmino() (fakeCreateValidatorMsgRepr, error) {
+	return fakeCreateValidatorMsgRepr{m.operator, m.delegator, m.pubKey, m.route, m.msgType}, nil
+}
+
+func (m *fakeCreateValidatorMsg) UnmarshalAmino(r fakeCreateValidatorMsgRepr) error {
+	*m = fakeCreateValidatorMsg{r.Operator, r.Delegator, r.PubKey, r.Route, r.MsgType}
+	return nil
+}
+
+var _ poaCreateValidatorMsg = fakeCreateValidatorMsg{}
+
+// fakeWeightedValidatorMsg additionally carries an explicit power, as a
+// BuildCreateValidatorMsgV2-built message would.
+type fakeWeightedValidatorMsg struct {
+	fakeCreateValidatorMsg
+	power   int64
+	moniker string
+}
+
+func (m fakeWeightedValidatorMsg) GetPower() int64    { return m.power }
+func (m fakeWeightedValidatorMsg) GetMoniker() string { return m.moniker }
+
+var _ poaWeightedValidatorMsg = fakeWeightedValidatorMsg{}
+
+func newWeightedGenTx(delegator sdk.AccAddress, pub crypto.PubKey, power int64) auth.StdTx {
+	return newWeightedGenTxWithMoniker(delegator, pub, power, "")
+}
+
+func newWeightedGenTxWithMoniker(delegator sdk.AccAddress, pub crypto.PubKey, power int64, moniker string) auth.StdTx {
+	msg := fakeWeightedValidatorMsg{
+		fakeCreateValidatorMsg: fakeCreateValidatorMsg{
+			operator:  sdk.ValAddress(delegator),
+			delegator: delegator,
+			pubKey:    pub,
+			route:     "poa",
+			msgType:   "create_validator",
+		},
+		power:   power,
+		moniker: moniker,
+	}
+	return auth.NewStdTx([]sdk.Msg{msg}, auth.StdFee{}, []auth.StdSignature{{}}, "")
+}
+
+func newGenTx(t *testing.T, route, msgType string, delegator sdk.AccAddress, pub crypto.PubKey) auth.StdTx {
+	t.Helper()
+	msg := fakeCreateValidatorMsg{
+		operator:  sdk.ValAddress(delegator),
+		delegator: delegator,
+		pubKey:    pub,
+		route:     route,
+		msgType:   msgType,
+	}
+	return auth.NewStdTx([]sdk.Msg{msg}, auth.StdFee{}, []auth.StdSignature{{}}, "")
+}
+
+// fakeGenAccIterator reports a fixed set of addresses as known genesis
+// accounts, independent of the genesisState it is handed.
+type fakeGenAccIterator struct {
+	known map[string]bool
+}
+
+func (f fakeGenAccIterator) IterateGenesisAccounts(
+	_ *codec.Codec, _ map[string]json.RawMessage, iterateFn func(authexported.Account) bool,
+) {
+	for addr := range f.known {
+		account := auth.NewBaseAccountWithAddress(mustAccAddressFromString(addr))
+		if iterateFn(&account) {
+			return
+		}
+	}
+}
+
+func mustAccAddressFromString(addr string) sdk.AccAddress {
+	bz, err := sdk.AccAddressFromBech32(addr)
+	if err != nil {
+		panic(err)
+	}
+	return bz
+}
+
+func TestValidateGenTxMsg(t *testing.T) {
+	delegator := sdk.AccAddress(ed25519.GenPrivKey().PubKey().Address())
+
+	t.Run("valid poa create-validator msg", func(t *testing.T) {
+		tx := newGenTx(t, "poa", "create_validator", delegator, ed25519.GenPrivKey().PubKey())
+		msg, err := validateGenTxMsg(tx)
+		require.NoError(t, err)
+		require.Equal(t, delegator, msg.GetDelegatorAddress())
+	})
+
+	t.Run("wrong route rejected", func(t *testing.T) {
+		tx := newGenTx(t, "staking", "create_validator", delegator, ed25519.GenPrivKey().PubKey())
+		_, err := validateGenTxMsg(tx)
+		require.Error(t, err)
+	})
+
+	t.Run("unsigned gentx rejected", func(t *testing.T) {
+		msg := fakeCreateValidatorMsg{
+			operator: sdk.ValAddress(delegator), delegator: delegator, pubKey: ed25519.GenPrivKey().PubKey(),
+			route: "poa", msgType: "create_validator",
+		}
+		tx := auth.NewStdTx([]sdk.Msg{msg}, auth.StdFee{}, []auth.StdSignature{}, "")
+		_, err := validateGenTxMsg(tx)
+		require.Error(t, err)
+	})
+
+	t.Run("more than one message rejected", func(t *testing.T) {
+		msg := fakeCreateValidatorMsg{
+			operator:  sdk.ValAddress(delegator),
+			delegator: delegator,
+			pubKey:    ed25519.GenPrivKey().PubKey(),
+			route:     "poa",
+			msgType:   "create_validator",
+		}
+		tx := auth.NewStdTx([]sdk.Msg{msg, msg}, auth.StdFee{}, []auth.StdSignature{}, "")
+		_, err := validateGenTxMsg(tx)
+		require.Error(t, err)
+	})
+}
+
+func TestProcessGenTxs(t *testing.T) {
+	delegatorA := sdk.AccAddress(ed25519.GenPrivKey().PubKey().Address())
+	delegatorB := sdk.AccAddress(ed25519.GenPrivKey().PubKey().Address())
+	pubKeyA := ed25519.GenPrivKey().PubKey()
+	pubKeyB := ed25519.GenPrivKey().PubKey()
+
+	known := fakeGenAccIterator{known: map[string]bool{
+		delegatorA.String(): true,
+		delegatorB.String(): true,
+	}}
+
+	t.Run("happy path collects both validators", func(t *testing.T) {
+		txs := []auth.StdTx{
+			newGenTx(t, "poa", "create_validator", delegatorA, pubKeyA),
+			newGenTx(t, "poa", "create_validator", delegatorB, pubKeyB),
+		}
+		validators, genTxs, err := processGenTxs(txs, map[string]json.RawMessage{}, known, nil, 0)
+		require.NoError(t, err)
+		require.Len(t, validators, 2)
+		require.Len(t, genTxs, 2)
+	})
+
+	t.Run("duplicate consensus pubkey rejected", func(t *testing.T) {
+		txs := []auth.StdTx{
+			newGenTx(t, "poa", "create_validator", delegatorA, pubKeyA),
+			newGenTx(t, "poa", "create_validator", delegatorB, pubKeyA),
+		}
+		_, _, err := processGenTxs(txs, map[string]json.RawMessage{}, known, nil, 0)
+		require.Error(t, err)
+	})
+
+	t.Run("missing genesis account rejected", func(t *testing.T) {
+		stranger := sdk.AccAddress(ed25519.GenPrivKey().PubKey().Address())
+		txs := []auth.StdTx{
+			newGenTx(t, "poa", "create_validator", stranger, pubKeyA),
+		}
+		_, _, err := processGenTxs(txs, map[string]json.RawMessage{}, known, nil, 0)
+		require.Error(t, err)
+	})
+
+	t.Run("malformed gentx (wrong message count) rejected", func(t *testing.T) {
+		msg := fakeCreateValidatorMsg{
+			operator: sdk.ValAddress(delegatorA), delegator: delegatorA, pubKey: pubKeyA,
+			route: "poa", msgType: "create_validator",
+		}
+		txs := []auth.StdTx{
+			auth.NewStdTx([]sdk.Msg{}, auth.StdFee{}, []auth.StdSignature{}, ""),
+			auth.NewStdTx([]sdk.Msg{msg, msg}, auth.StdFee{}, []auth.StdSignature{}, ""),
+		}
+		_, _, err := processGenTxs(txs, map[string]json.RawMessage{}, known, nil, 0)
+		require.Error(t, err)
+	})
+
+	t.Run("weighted validator within max-power-fraction accepted", func(t *testing.T) {
+		txs := []auth.StdTx{
+			newWeightedGenTx(delegatorA, pubKeyA, 60),
+			newWeightedGenTx(delegatorB, pubKeyB, 40),
+		}
+		validators, _, err := processGenTxs(txs, map[string]json.RawMessage{}, known, nil, 0.75)
+		require.NoError(t, err)
+		require.Equal(t, int64(60), validators[0].Power)
+		require.Equal(t, int64(40), validators[1].Power)
+	})
+
+	t.Run("weighted validator name falls back to operator address without a moniker", func(t *testing.T) {
+		txs := []auth.StdTx{newWeightedGenTx(delegatorA, pubKeyA, 10)}
+		validators, _, err := processGenTxs(txs, map[string]json.RawMessage{}, known, nil, 0)
+		require.NoError(t, err)
+		require.Equal(t, sdk.ValAddress(delegatorA).String(), validators[0].Name)
+	})
+
+	t.Run("weighted validator name uses moniker when set", func(t *testing.T) {
+		txs := []auth.StdTx{newWeightedGenTxWithMoniker(delegatorA, pubKeyA, 10, "alice-node")}
+		validators, _, err := processGenTxs(txs, map[string]json.RawMessage{}, known, nil, 0)
+		require.NoError(t, err)
+		require.Equal(t, "alice-node", validators[0].Name)
+	})
+
+	t.Run("weighted validator exceeding max-power-fraction rejected", func(t *testing.T) {
+		txs := []auth.StdTx{
+			newWeightedGenTx(delegatorA, pubKeyA, 80),
+			newWeightedGenTx(delegatorB, pubKeyB, 20),
+		}
+		_, _, err := processGenTxs(txs, map[string]json.RawMessage{}, known, nil, 0.75)
+		require.Error(t, err)
+	})
+}
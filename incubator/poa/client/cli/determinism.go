@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/auth"
+)
+
+const gentxHashLen = 16
+
+// canonicalJSON re-encodes raw as JSON with object keys sorted and no
+// insignificant whitespace, so semantically identical txs always produce the
+// same bytes regardless of the field order amino happened to emit them in.
+func canonicalJSON(raw []byte) ([]byte, error) {
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
+
+// gentxHash returns the first gentxHashLen hex characters of the sha256 sum
+// of tx's canonical JSON encoding.
+func gentxHash(cdc *codec.Codec, tx auth.StdTx) (string, error) {
+	raw, err := cdc.MarshalJSON(tx)
+	if err != nil {
+		return "", err
+	}
+	canon, err := canonicalJSON(raw)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canon)
+	return hex.EncodeToString(sum[:])[:gentxHashLen], nil
+}
+
+// poaFundedValidatorMsg is an optional extension of poaCreateValidatorMsg
+// implemented by self-delegation-style messages (built via
+// BuildCreateValidatorMsg), exposing the bonded amount that's part of a
+// gentx's identifying tuple.
+type poaFundedValidatorMsg interface {
+	poaCreateValidatorMsg
+	GetAmount() sdk.Coins
+}
+
+// validatorTupleKey returns a stable string identifying the (nodeID,
+// operator, pubkey, amount) a gentx creates a validator for, used for
+// duplicate-tuple detection. It returns "" if msg doesn't expose enough
+// information to build one (e.g. it isn't a poaCreateValidatorMsg), in which
+// case the caller should skip the check rather than false-positive.
+//
+// Messages built via BuildCreateValidatorMsgV2 carry an explicit consensus
+// power instead of a self-delegation amount; for those the power takes the
+// amount's place in the tuple, since it plays the same role of "how much
+// weight is this gentx asking for".
+func validatorTupleKey(nodeID string, msg sdk.Msg) string {
+	cvm, ok := msg.(poaCreateValidatorMsg)
+	if !ok {
+		return ""
+	}
+
+	weight := "0"
+	switch m := cvm.(type) {
+	case poaFundedValidatorMsg:
+		weight = m.GetAmount().String()
+	case poaWeightedValidatorMsg:
+		weight = fmt.Sprintf("power:%d", m.GetPower())
+	}
+
+	return fmt.Sprintf("%s/%s/%x/%s", nodeID, cvm.GetValidatorAddress().String(), cvm.GetPubKey().Bytes(), weight)
+}
+
+// checkDeterministicOutput scans dir for a previously-written gentx for the
+// same validatorTupleKey (nodeID, operator, pubkey, amount or power) and
+// returns an error if it hashed to
+// something other than newHash - i.e. the same inputs produced two different
+// gentx files, which points at a nondeterministic field (memo, timestamp...)
+// sneaking into the signed tx.
+func checkDeterministicOutput(dir, tupleKey, newHash string, cdc *codec.Codec) error {
+	if tupleKey == "" {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "gentx-*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		bz, err := ioutil.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var tx auth.StdTx
+		if err := cdc.UnmarshalJSON(bz, &tx); err != nil {
+			continue
+		}
+		msgs := tx.GetMsgs()
+		if len(msgs) != 1 {
+			continue
+		}
+
+		existingHash, err := gentxHash(cdc, tx)
+		if err != nil {
+			continue
+		}
+
+		for _, nodeID := range candidateNodeIDs(f) {
+			if validatorTupleKey(nodeID, msgs[0]) == tupleKey && existingHash != newHash {
+				return fmt.Errorf(
+					"a gentx for the same validator already exists at %s with hash %s, but this run produced %s; "+
+						"check for nondeterministic fields (memo, timestamps) in the signed tx", f, existingHash, newHash)
+			}
+		}
+	}
+	return nil
+}
+
+// candidateNodeIDs extracts the nodeID component out of both the legacy
+// gentx-<nodeID>.json and the content-addressable gentx-<nodeID>-<hash>.json
+// filenames, since either may be present in a gentx directory.
+func candidateNodeIDs(path string) []string {
+	base := filepath.Base(path)
+	base = base[len("gentx-") : len(base)-len(".json")]
+	if idx := strings.LastIndex(base, "-"); idx >= 0 {
+		return []string{base, base[:idx]}
+	}
+	return []string{base}
+}
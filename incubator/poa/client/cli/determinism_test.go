@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tendermint/tendermint/crypto/ed25519"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// fakeFundedValidatorMsg additionally carries a self-delegation amount, as a
+// BuildCreateValidatorMsg-built message would.
+type fakeFundedValidatorMsg struct {
+	fakeCreateValidatorMsg
+	amount sdk.Coins
+}
+
+func (m fakeFundedValidatorMsg) GetAmount() sdk.Coins { return m.amount }
+
+var _ poaFundedValidatorMsg = fakeFundedValidatorMsg{}
+
+func TestCanonicalJSON(t *testing.T) {
+	a := []byte(`{"b": 2, "a": 1, "c": {"y": 2, "x": 1}}`)
+	b := []byte(`{"a":1,   "c": {"x":1,"y":2}, "b":2}`)
+
+	canonA, err := canonicalJSON(a)
+	require.NoError(t, err)
+	canonB, err := canonicalJSON(b)
+	require.NoError(t, err)
+
+	require.Equal(t, string(canonA), string(canonB))
+	require.Equal(t, `{"a":1,"b":2,"c":{"x":1,"y":2}}`, string(canonA))
+}
+
+func TestCandidateNodeIDs(t *testing.T) {
+	require.Equal(t, []string{"abc123"}, candidateNodeIDs("/tmp/gentx/gentx-abc123.json"))
+	require.Equal(t, []string{"abc123-deadbeefcafebabe", "abc123"},
+		candidateNodeIDs("/tmp/gentx/gentx-abc123-deadbeefcafebabe.json"))
+}
+
+func TestValidatorTupleKey(t *testing.T) {
+	delegator := sdk.AccAddress(ed25519.GenPrivKey().PubKey().Address())
+	pub := ed25519.GenPrivKey().PubKey()
+
+	base := fakeCreateValidatorMsg{
+		operator:  sdk.ValAddress(delegator),
+		delegator: delegator,
+		pubKey:    pub,
+		route:     "poa",
+		msgType:   "create_validator",
+	}
+
+	t.Run("changing the self-delegation amount changes the tuple key", func(t *testing.T) {
+		small := fakeFundedValidatorMsg{fakeCreateValidatorMsg: base, amount: sdk.NewCoins(sdk.NewInt64Coin("stake", 100))}
+		large := fakeFundedValidatorMsg{fakeCreateValidatorMsg: base, amount: sdk.NewCoins(sdk.NewInt64Coin("stake", 200))}
+
+		require.NotEqual(t, validatorTupleKey("node1", small), validatorTupleKey("node1", large))
+	})
+
+	t.Run("same amount produces the same tuple key", func(t *testing.T) {
+		a := fakeFundedValidatorMsg{fakeCreateValidatorMsg: base, amount: sdk.NewCoins(sdk.NewInt64Coin("stake", 100))}
+		b := fakeFundedValidatorMsg{fakeCreateValidatorMsg: base, amount: sdk.NewCoins(sdk.NewInt64Coin("stake", 100))}
+
+		require.Equal(t, validatorTupleKey("node1", a), validatorTupleKey("node1", b))
+	})
+
+	t.Run("changing power changes the tuple key for weighted validators", func(t *testing.T) {
+		light := fakeWeightedValidatorMsg{fakeCreateValidatorMsg: base, power: 10}
+		heavy := fakeWeightedValidatorMsg{fakeCreateValidatorMsg: base, power: 20}
+
+		require.NotEqual(t, validatorTupleKey("node1", light), validatorTupleKey("node1", heavy))
+	})
+}
@@ -1,13 +1,16 @@
 package cli
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -17,6 +20,7 @@ import (
 
 	cfg "github.com/tendermint/tendermint/config"
 	"github.com/tendermint/tendermint/crypto"
+	"github.com/tendermint/tendermint/crypto/multisig"
 	tmos "github.com/tendermint/tendermint/libs/os"
 	tmtypes "github.com/tendermint/tendermint/types"
 
@@ -34,6 +38,20 @@ import (
 	"github.com/cosmos/cosmos-sdk/x/genutil/types"
 )
 
+const (
+	flagMultisigSigners = "multisig-signers"
+	flagSignatureFile   = "signature-file"
+	flagAddGenTxNodeID  = "node-id"
+	flagDeterministic   = "deterministic"
+	flagPower           = "power"
+	flagMoniker         = "moniker"
+	flagIdentity        = "identity"
+	flagWebsite         = "website"
+	flagSecurityContact = "security-contact"
+	flagDetails         = "details"
+	flagMetadataFile    = "metadata-file"
+)
+
 // StakingMsgBuildingHelpers helpers for message building gen-tx command
 type POAMsgBuildingHelpers interface {
 	CreateValidatorMsgHelpers(ipDefault string) (fs *flag.FlagSet, nodeIDFlag, pubkeyFlag, amountFlag, defaultsDesc string)
@@ -41,6 +59,27 @@ type POAMsgBuildingHelpers interface {
 	BuildCreateValidatorMsg(cliCtx context.CLIContext, txBldr auth.TxBuilder) (auth.TxBuilder, sdk.Msg, error)
 }
 
+// Description carries the human-readable validator metadata BuildCreateValidatorMsgV2
+// accepts in addition to a raw consensus power, so POA chains without a
+// staking token can still seed a moniker/identity/etc. at genesis.
+type Description struct {
+	Moniker         string          `json:"moniker"`
+	Identity        string          `json:"identity,omitempty"`
+	Website         string          `json:"website,omitempty"`
+	SecurityContact string          `json:"security_contact,omitempty"`
+	Details         string          `json:"details,omitempty"`
+	Metadata        json.RawMessage `json:"metadata,omitempty"`
+}
+
+// POAMsgBuildingHelpersV2 is an optional extension of POAMsgBuildingHelpers for
+// chains that want to seed a weighted validator set directly, by raw
+// consensus power, instead of pretending to bond a self-delegation amount.
+// GenTxCmd prefers it over BuildCreateValidatorMsg whenever a
+// POAMsgBuildingHelpers implementation also satisfies this interface.
+type POAMsgBuildingHelpersV2 interface {
+	BuildCreateValidatorMsgV2(cliCtx context.CLIContext, txBldr auth.TxBuilder, power int64, description Description) (auth.TxBuilder, sdk.Msg, error)
+}
+
 // GenTxCmd builds the application's gentx command.
 // nolint: errcheck
 func GenTxCmd(ctx *server.Context, cdc *codec.Codec, mbm module.BasicManager, pmbh POAMsgBuildingHelpers,
@@ -114,6 +153,10 @@ func GenTxCmd(ctx *server.Context, cdc *codec.Codec, mbm module.BasicManager, pm
 				return errors.Wrap(err, "failed to validate account in genesis")
 			}
 
+			// Remember whether the operator asked for an unsigned gentx before
+			// it gets forced on below for message building purposes.
+			generateOnly := viper.GetBool(client.FlagGenerateOnly)
+
 			txBldr := auth.NewTxBuilderFromCLI().WithTxEncoder(utils.GetTxEncoder(cdc))
 			cliCtx := client.NewCLIContext().WithCodec(cdc)
 
@@ -124,10 +167,23 @@ func GenTxCmd(ctx *server.Context, cdc *codec.Codec, mbm module.BasicManager, pm
 			// favor of a 'gentx' flag in the create-validator command.
 			viper.Set(client.FlagGenerateOnly, true)
 
-			// create a 'create-validator' message
-			txBldr, msg, err := pmbh.BuildCreateValidatorMsg(cliCtx, txBldr)
-			if err != nil {
-				return errors.Wrap(err, "failed to build create-validator message")
+			// create a 'create-validator' message, preferring the V2 builder
+			// (explicit power + description) when the helpers implement it
+			var msg sdk.Msg
+			if pmbhV2, ok := pmbh.(POAMsgBuildingHelpersV2); ok {
+				description, err := descriptionFromFlags()
+				if err != nil {
+					return errors.Wrap(err, "failed to read validator description")
+				}
+				txBldr, msg, err = pmbhV2.BuildCreateValidatorMsgV2(cliCtx, txBldr, viper.GetInt64(flagPower), description)
+				if err != nil {
+					return errors.Wrap(err, "failed to build create-validator message")
+				}
+			} else {
+				txBldr, msg, err = pmbh.BuildCreateValidatorMsg(cliCtx, txBldr)
+				if err != nil {
+					return errors.Wrap(err, "failed to build create-validator message")
+				}
 			}
 
 			info, err := txBldr.Keybase().Get(name)
@@ -135,11 +191,62 @@ func GenTxCmd(ctx *server.Context, cdc *codec.Codec, mbm module.BasicManager, pm
 				return errors.Wrap(err, "failed to read from tx builder keybase")
 			}
 
+			if generateOnly {
+				// write the unsigned transaction to the buffer
+				w := bytes.NewBuffer([]byte{})
+				cliCtx = cliCtx.WithOutput(w)
+
+				if err = utils.PrintUnsignedStdTx(txBldr, cliCtx, []sdk.Msg{msg}); err != nil {
+					return errors.Wrap(err, "failed to print unsigned std tx")
+				}
+
+				stdTx, err := readUnsignedGenTxFile(cdc, w)
+				if err != nil {
+					return errors.Wrap(err, "failed to read unsigned gen tx file")
+				}
+
+				outputDocument, err := resolveOutputDocument(cdc, config.RootDir, nodeID, msg, stdTx)
+				if err != nil {
+					return errors.Wrap(err, "failed to resolve output file path")
+				}
+
+				if err := writeSignedGenTx(cdc, outputDocument, stdTx); err != nil {
+					return errors.Wrap(err, "failed to write unsigned gen tx")
+				}
+
+				fmt.Fprintf(os.Stderr, "Unsigned genesis transaction written to %q\n", outputDocument)
+				return nil
+			}
+
+			if info.GetType() == kbkeys.TypeMulti && viper.GetString(flagMultisigSigners) != "" {
+				signedTx, err := signMultisigGenTx(txBldr, cliCtx, info, msg)
+				if err != nil {
+					return errors.Wrap(err, "failed to assemble multisig gen tx")
+				}
+
+				outputDocument, err := resolveOutputDocument(cdc, config.RootDir, nodeID, msg, signedTx)
+				if err != nil {
+					return errors.Wrap(err, "failed to resolve output file path")
+				}
+
+				if err := writeSignedGenTx(cdc, outputDocument, signedTx); err != nil {
+					return errors.Wrap(err, "failed to write signed gen tx")
+				}
+
+				fmt.Fprintf(os.Stderr, "Genesis transaction written to %q\n", outputDocument)
+				return nil
+			}
+
 			if info.GetType() == kbkeys.TypeOffline || info.GetType() == kbkeys.TypeMulti {
 				fmt.Println("Offline key passed in. Use `tx sign` command to sign:")
 				return utils.PrintUnsignedStdTx(txBldr, cliCtx, []sdk.Msg{msg})
 			}
 
+			isLedger := info.GetType() == kbkeys.TypeLedger
+			if isLedger {
+				fmt.Fprintln(os.Stderr, "Confirm the create-validator transaction on your Ledger device...")
+			}
+
 			// write the unsigned transaction to the buffer
 			w := bytes.NewBuffer([]byte{})
 			cliCtx = cliCtx.WithOutput(w)
@@ -154,19 +261,20 @@ func GenTxCmd(ctx *server.Context, cdc *codec.Codec, mbm module.BasicManager, pm
 				return errors.Wrap(err, "failed to read unsigned gen tx file")
 			}
 
-			// sign the transaction and write it to the output file
+			// sign the transaction (the Ledger, if any, is driven transparently
+			// through the tx builder's keybase) and write it to the output file
 			signedTx, err := utils.SignStdTx(txBldr, cliCtx, name, stdTx, false, true)
 			if err != nil {
+				if isLedger {
+					return errors.Wrap(err, "failed to sign std tx with Ledger device; "+
+						"confirm it is connected, unlocked, and has the correct app open, then retry")
+				}
 				return errors.Wrap(err, "failed to sign std tx")
 			}
 
-			// Fetch output file name
-			outputDocument := viper.GetString(client.FlagOutputDocument)
-			if outputDocument == "" {
-				outputDocument, err = makeOutputFilepath(config.RootDir, nodeID)
-				if err != nil {
-					return errors.Wrap(err, "failed to create output file path")
-				}
+			outputDocument, err := resolveOutputDocument(cdc, config.RootDir, nodeID, msg, signedTx)
+			if err != nil {
+				return errors.Wrap(err, "failed to resolve output file path")
 			}
 
 			if err := writeSignedGenTx(cdc, outputDocument, signedTx); err != nil {
@@ -184,18 +292,266 @@ func GenTxCmd(ctx *server.Context, cdc *codec.Codec, mbm module.BasicManager, pm
 	cmd.Flags().String(client.FlagName, "", "name of private key with which to sign the gentx")
 	cmd.Flags().String(client.FlagOutputDocument, "",
 		"write the genesis transaction JSON document to the given file instead of the default location")
+	cmd.Flags().String(flagMultisigSigners, "",
+		"comma-separated list of key names backing the multisig, in the order they appear in the multisig pubkey")
+	cmd.Flags().StringSlice(flagSignatureFile, []string{},
+		"path to a file holding a signer's base64-encoded signature, one per --multisig-signers entry in the same order; "+
+			"signers without a file are prompted for a base64-encoded signature on stdin")
+	cmd.Flags().Bool(client.FlagGenerateOnly, false,
+		"write an unsigned gentx to the output document instead of signing it locally, for offline or Ledger signing")
+	cmd.Flags().Bool(flagDeterministic, false,
+		"fail if this (nodeID, operator, pubkey, amount or power) tuple already produced a gentx with a different hash")
+	cmd.Flags().Int64(flagPower, 1, "raw consensus power to seed this validator with (only used by BuildCreateValidatorMsgV2 helpers)")
+	cmd.Flags().String(flagMoniker, "", "validator moniker (only used by BuildCreateValidatorMsgV2 helpers)")
+	cmd.Flags().String(flagIdentity, "", "validator identity signature (optional, only used by BuildCreateValidatorMsgV2 helpers)")
+	cmd.Flags().String(flagWebsite, "", "validator website (optional, only used by BuildCreateValidatorMsgV2 helpers)")
+	cmd.Flags().String(flagSecurityContact, "", "validator security contact (optional, only used by BuildCreateValidatorMsgV2 helpers)")
+	cmd.Flags().String(flagDetails, "", "validator details (optional, only used by BuildCreateValidatorMsgV2 helpers)")
+	cmd.Flags().String(flagMetadataFile, "",
+		"path to a JSON file merged into the validator's description metadata (optional, only used by BuildCreateValidatorMsgV2 helpers)")
 	cmd.Flags().AddFlagSet(fsCreateValidator)
 
 	cmd.MarkFlagRequired(client.FlagName)
 	return cmd
 }
 
-func makeOutputFilepath(rootDir, nodeID string) (string, error) {
-	writePath := filepath.Join(rootDir, "config", "gentx")
-	if err := tmos.EnsureDir(writePath, 0700); err != nil {
+// descriptionFromFlags assembles a Description from --moniker, --identity,
+// --website, --security-contact, --details and --metadata-file for
+// BuildCreateValidatorMsgV2 helpers.
+func descriptionFromFlags() (Description, error) {
+	description := Description{
+		Moniker:         viper.GetString(flagMoniker),
+		Identity:        viper.GetString(flagIdentity),
+		Website:         viper.GetString(flagWebsite),
+		SecurityContact: viper.GetString(flagSecurityContact),
+		Details:         viper.GetString(flagDetails),
+	}
+
+	if metadataFile := viper.GetString(flagMetadataFile); metadataFile != "" {
+		raw, err := ioutil.ReadFile(metadataFile)
+		if err != nil {
+			return Description{}, errors.Wrapf(err, "failed to read metadata file %s", metadataFile)
+		}
+		if !json.Valid(raw) {
+			return Description{}, fmt.Errorf("metadata file %s does not contain valid JSON", metadataFile)
+		}
+		description.Metadata = json.RawMessage(raw)
+	}
+
+	return description, nil
+}
+
+// signMultisigGenTx gathers one partial signature per name in --multisig-signers,
+// verifies each against the pubkey the multisig Info was registered with, and
+// assembles them into a single multisig.Multisignature on the gentx. Partial
+// signatures are read from the matching --signature-file entry when present,
+// falling back to an interactive base64 prompt on stdin.
+func signMultisigGenTx(txBldr auth.TxBuilder, cliCtx context.CLIContext, multisigInfo kbkeys.Info, msg sdk.Msg) (auth.StdTx, error) {
+	multisigPub, ok := multisigInfo.GetPubKey().(multisig.PubKeyMultisigThreshold)
+	if !ok {
+		return auth.StdTx{}, fmt.Errorf("key %q is not a multisig pubkey", multisigInfo.GetName())
+	}
+
+	signMsg, err := txBldr.BuildSignMsg([]sdk.Msg{msg})
+	if err != nil {
+		return auth.StdTx{}, errors.Wrap(err, "failed to build gentx sign message")
+	}
+
+	multisigSig, err := aggregateMultisigSignatures(multisigPub, signMsg.Bytes(), func(name string) (crypto.PubKey, error) {
+		signerInfo, err := txBldr.Keybase().Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return signerInfo.GetPubKey(), nil
+	})
+	if err != nil {
+		return auth.StdTx{}, err
+	}
+
+	stdSig := auth.StdSignature{
+		PubKey:    multisigPub,
+		Signature: cliCtx.Codec.MustMarshalBinaryBare(multisigSig),
+	}
+
+	return auth.NewStdTx(signMsg.Msgs, signMsg.Fee, []auth.StdSignature{stdSig}, signMsg.Memo), nil
+}
+
+// aggregateMultisigSignatures gathers one partial signature per name in
+// --multisig-signers, verifies each against the pubkey lookup resolves that
+// name to, and assembles them into a single multisig.Multisignature over
+// signBytes. Partial signatures are read from the matching --signature-file
+// entry when present, falling back to an interactive base64 prompt on stdin.
+// Pulled out of signMultisigGenTx so the aggregation logic can be exercised
+// without a real keybase or tx builder.
+func aggregateMultisigSignatures(multisigPub multisig.PubKeyMultisigThreshold, signBytes []byte, lookup func(name string) (crypto.PubKey, error)) (*multisig.Multisignature, error) {
+	signerNames := strings.Split(viper.GetString(flagMultisigSigners), ",")
+	if len(signerNames) < int(multisigPub.K) {
+		return nil, fmt.Errorf("multisig requires %d signatures, only %d signer names given", multisigPub.K, len(signerNames))
+	}
+
+	sigFiles := viper.GetStringSlice(flagSignatureFile)
+	multisigSig := multisig.NewMultisig(len(multisigPub.PubKeys))
+
+	for i, rawName := range signerNames {
+		name := strings.TrimSpace(rawName)
+
+		pubKey, err := lookup(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to look up multisig signer %q", name)
+		}
+
+		var sig []byte
+		if i < len(sigFiles) && sigFiles[i] != "" {
+			sig, err = readSignatureFile(sigFiles[i])
+		} else {
+			sig, err = promptSignature(name)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to obtain signature for %q", name)
+		}
+
+		if !pubKey.VerifyBytes(signBytes, sig) {
+			return nil, fmt.Errorf("signature for %q does not verify against its registered pubkey", name)
+		}
+
+		if err := multisigSig.AddSignatureFromPubKey(sig, pubKey, multisigPub.PubKeys); err != nil {
+			return nil, errors.Wrapf(err, "failed to add signature for %q to multisig", name)
+		}
+	}
+
+	return multisigSig, nil
+}
+
+// readSignatureFile reads a single base64-encoded partial signature from path.
+func readSignatureFile(path string) ([]byte, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+}
+
+// promptSignature asks the operator to paste a base64-encoded partial
+// signature produced out-of-band (e.g. via `tx sign --signature-only`) for
+// the named multisig constituent.
+func promptSignature(name string) ([]byte, error) {
+	fmt.Printf("Enter base64-encoded signature for %q: ", name)
+	reader := bufio.NewReader(os.Stdin)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(line))
+}
+
+// AddGenTxCmd builds the command that takes a StdTx signed out-of-band (e.g.
+// offline or on a Ledger device via `gentx --generate-only` followed by
+// `tx sign`) and files it under config/gentx/ with the same naming GenTxCmd
+// uses, so it can be picked up by collect-gentxs alongside locally signed
+// gentxs.
+func AddGenTxCmd(ctx *server.Context, cdc *codec.Codec, defaultNodeHome string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add-gentx [signed-tx-file]",
+		Short: "Stage an already-signed gentx for collect-gentxs",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config := ctx.Config
+			config.SetRoot(viper.GetString(client.FlagHome))
+
+			nodeID, _, err := genutil.InitializeNodeValidatorFiles(config)
+			if err != nil {
+				return errors.Wrap(err, "failed to initialize node validator files")
+			}
+			if nodeIDString := viper.GetString(flagAddGenTxNodeID); nodeIDString != "" {
+				nodeID = nodeIDString
+			}
+
+			outputDocument, err := addGenTx(cdc, config.RootDir, nodeID, args[0])
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "Genesis transaction written to %q\n", outputDocument)
+			return nil
+		},
+	}
+
+	cmd.Flags().String(client.FlagHome, defaultNodeHome, "node's home directory")
+	cmd.Flags().String(flagAddGenTxNodeID, "", "node's ID")
+	cmd.Flags().String(client.FlagOutputDocument, "",
+		"write the genesis transaction JSON document to the given file instead of the default location")
+	return cmd
+}
+
+// addGenTx reads a signed StdTx from path, validates it, and stages it under
+// rootDir for collect-gentxs. Pulled out of AddGenTxCmd's RunE so it can be
+// exercised without a cobra/server.Context harness.
+func addGenTx(cdc *codec.Codec, rootDir, nodeID, path string) (string, error) {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read signed tx file %s", path)
+	}
+
+	var signedTx auth.StdTx
+	if err := cdc.UnmarshalJSON(bz, &signedTx); err != nil {
+		return "", errors.Wrap(err, "failed to parse signed tx file")
+	}
+
+	return stageGenTx(cdc, rootDir, nodeID, signedTx)
+}
+
+// stageGenTx validates signedTx and writes it to its resolved gentx path
+// under rootDir, returning the path it was written to.
+func stageGenTx(cdc *codec.Codec, rootDir, nodeID string, signedTx auth.StdTx) (string, error) {
+	msg, err := validateGenTxMsg(signedTx)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid gentx")
+	}
+
+	outputDocument, err := resolveOutputDocument(cdc, rootDir, nodeID, msg, signedTx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve output file path")
+	}
+
+	if err := writeSignedGenTx(cdc, outputDocument, signedTx); err != nil {
+		return "", errors.Wrap(err, "failed to write signed gen tx")
+	}
+
+	return outputDocument, nil
+}
+
+// resolveOutputDocument picks the path a gentx should be written to: the
+// explicit --output-document when given, otherwise a content-addressable
+// gentx-<nodeID>-<hash>.json computed from tx's canonical JSON encoding. With
+// --deterministic it also refuses to proceed if the same (nodeID, operator,
+// pubkey, amount or power) tuple previously produced a gentx with a different
+// hash.
+func resolveOutputDocument(cdc *codec.Codec, rootDir, nodeID string, msg sdk.Msg, tx auth.StdTx) (string, error) {
+	if explicit := viper.GetString(client.FlagOutputDocument); explicit != "" {
+		return explicit, nil
+	}
+
+	gentxDir := filepath.Join(rootDir, "config", "gentx")
+	if err := tmos.EnsureDir(gentxDir, 0700); err != nil {
 		return "", err
 	}
-	return filepath.Join(writePath, fmt.Sprintf("gentx-%v.json", nodeID)), nil
+
+	hash, err := gentxHash(cdc, tx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to hash gentx")
+	}
+
+	if viper.GetBool(flagDeterministic) {
+		if err := checkDeterministicOutput(gentxDir, validatorTupleKey(nodeID, msg), hash, cdc); err != nil {
+			return "", err
+		}
+	}
+
+	return makeOutputFilepath(gentxDir, nodeID, hash), nil
+}
+
+func makeOutputFilepath(gentxDir, nodeID, hash string) string {
+	return filepath.Join(gentxDir, fmt.Sprintf("gentx-%s-%s.json", nodeID, hash))
 }
 
 func readUnsignedGenTxFile(cdc *codec.Codec, r io.Reader) (auth.StdTx, error) {
@@ -214,10 +570,16 @@ func writeSignedGenTx(cdc *codec.Codec, outputDocument string, tx auth.StdTx) er
 		return err
 	}
 	defer outputFile.Close()
-	json, err := cdc.MarshalJSON(tx)
+
+	raw, err := cdc.MarshalJSON(tx)
 	if err != nil {
 		return err
 	}
-	_, err = fmt.Fprintf(outputFile, "%s\n", json)
+	canon, err := canonicalJSON(raw)
+	if err != nil {
+		return err
+	}
+
+	_, err = outputFile.Write(canon)
 	return err
 }
\ No newline at end of file
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tendermint/tendermint/crypto"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+	"github.com/tendermint/tendermint/crypto/multisig"
+)
+
+// resetMultisigFlags clears the viper state aggregateMultisigSignatures reads,
+// restoring it after the test so subtests don't leak into one another.
+func resetMultisigFlags(t *testing.T) {
+	t.Helper()
+	viper.Set(flagMultisigSigners, "")
+	viper.Set(flagSignatureFile, []string{})
+	t.Cleanup(func() {
+		viper.Set(flagMultisigSigners, "")
+		viper.Set(flagSignatureFile, []string{})
+	})
+}
+
+// writeSigFile base64-encodes sig and writes it to a fresh file under t's
+// temp dir, returning the path.
+func writeSigFile(t *testing.T, name string, sig []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	encoded := base64.StdEncoding.EncodeToString(sig)
+	require.NoError(t, ioutil.WriteFile(path, []byte(encoded+"\n"), 0600))
+	return path
+}
+
+func TestAggregateMultisigSignatures(t *testing.T) {
+	signBytes := []byte("gentx sign bytes")
+
+	priv1 := ed25519.GenPrivKey()
+	priv2 := ed25519.GenPrivKey()
+	priv3 := ed25519.GenPrivKey()
+	pubs := []crypto.PubKey{priv1.PubKey(), priv2.PubKey(), priv3.PubKey()}
+	multisigPub := multisig.NewPubKeyMultisigThreshold(2, pubs).(multisig.PubKeyMultisigThreshold)
+
+	lookup := func(name string) (crypto.PubKey, error) {
+		switch name {
+		case "signer1":
+			return priv1.PubKey(), nil
+		case "signer2":
+			return priv2.PubKey(), nil
+		case "signer3":
+			return priv3.PubKey(), nil
+		default:
+			return nil, os.ErrNotExist
+		}
+	}
+
+	t.Run("aggregates a valid k-of-n signature set", func(t *testing.T) {
+		resetMultisigFlags(t)
+
+		sig1, err := priv1.Sign(signBytes)
+		require.NoError(t, err)
+		sig2, err := priv2.Sign(signBytes)
+		require.NoError(t, err)
+
+		viper.Set(flagMultisigSigners, "signer1,signer2")
+		viper.Set(flagSignatureFile, []string{
+			writeSigFile(t, "sig1", sig1),
+			writeSigFile(t, "sig2", sig2),
+		})
+
+		multisigSig, err := aggregateMultisigSignatures(multisigPub, signBytes, lookup)
+		require.NoError(t, err)
+		require.Equal(t, 2, multisigSig.BitArray.NumTrueBitsBefore(multisigSig.BitArray.Size()))
+	})
+
+	t.Run("rejects a signature that fails to verify", func(t *testing.T) {
+		resetMultisigFlags(t)
+
+		sig1, err := priv1.Sign(signBytes)
+		require.NoError(t, err)
+		// sig2 is signed by the wrong key for "signer2".
+		wrongSig, err := priv3.Sign(signBytes)
+		require.NoError(t, err)
+
+		viper.Set(flagMultisigSigners, "signer1,signer2")
+		viper.Set(flagSignatureFile, []string{
+			writeSigFile(t, "sig1", sig1),
+			writeSigFile(t, "sig2", wrongSig),
+		})
+
+		_, err = aggregateMultisigSignatures(multisigPub, signBytes, lookup)
+		require.Error(t, err)
+	})
+
+	t.Run("rejects fewer signer names than the multisig threshold", func(t *testing.T) {
+		resetMultisigFlags(t)
+
+		viper.Set(flagMultisigSigners, "signer1")
+
+		_, err := aggregateMultisigSignatures(multisigPub, signBytes, lookup)
+		require.Error(t, err)
+	})
+
+	t.Run("surfaces a bad signature file path", func(t *testing.T) {
+		resetMultisigFlags(t)
+
+		viper.Set(flagMultisigSigners, "signer1,signer2")
+		viper.Set(flagSignatureFile, []string{
+			filepath.Join(t.TempDir(), "does-not-exist"),
+			filepath.Join(t.TempDir(), "also-does-not-exist"),
+		})
+
+		_, err := aggregateMultisigSignatures(multisigPub, signBytes, lookup)
+		require.Error(t, err)
+	})
+}
+
+func TestReadSignatureFile(t *testing.T) {
+	t.Run("decodes a valid base64 signature", func(t *testing.T) {
+		sig := []byte("partial signature bytes")
+		path := writeSigFile(t, "sig", sig)
+
+		decoded, err := readSignatureFile(path)
+		require.NoError(t, err)
+		require.Equal(t, sig, decoded)
+	})
+
+	t.Run("errors on a missing file", func(t *testing.T) {
+		_, err := readSignatureFile(filepath.Join(t.TempDir(), "missing"))
+		require.Error(t, err)
+	})
+
+	t.Run("errors on malformed base64 content", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bad-sig")
+		require.NoError(t, ioutil.WriteFile(path, []byte("not-valid-base64!!"), 0600))
+
+		_, err := readSignatureFile(path)
+		require.Error(t, err)
+	})
+}
+
+func TestPromptSignature(t *testing.T) {
+	sig := []byte("signature from stdin")
+	encoded := base64.StdEncoding.EncodeToString(sig)
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+
+	oldStdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = oldStdin }()
+
+	_, err = w.WriteString(encoded + "\n")
+	require.NoError(t, err)
+	w.Close()
+
+	decoded, err := promptSignature("signer1")
+	require.NoError(t, err)
+	require.Equal(t, sig, decoded)
+}